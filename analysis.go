@@ -0,0 +1,255 @@
+package parsing
+
+// RuneSet is a set of terminal runes, used by FirstSet/FollowSet to
+// describe which characters can appear at a given position in a
+// grammar without paying for a full interval representation; grammar
+// alphabets are small enough in practice (ASCII punctuation, a few
+// Unicode ranges) that expanding ranges into individual runes is
+// cheap and keeps the rest of this pass simple.
+type RuneSet map[rune]struct{}
+
+func newRuneSet(runes ...rune) RuneSet {
+	set := make(RuneSet, len(runes))
+	for _, r := range runes {
+		set[r] = struct{}{}
+	}
+	return set
+}
+
+// merge adds every rune of other into set, reporting whether set grew
+// as a result. Used to detect convergence in the fixed-point passes
+// below.
+func (set RuneSet) merge(other RuneSet) bool {
+	changed := false
+	for r := range other {
+		if _, ok := set[r]; !ok {
+			set[r] = struct{}{}
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (set RuneSet) sorted() []rune {
+	out := make([]rune, 0, len(set))
+	for r := range set {
+		out = append(out, r)
+	}
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && out[j-1] > out[j]; j-- {
+			out[j-1], out[j] = out[j], out[j-1]
+		}
+	}
+	return out
+}
+
+// FirstSet maps each rule name to the set of terminal runes that can
+// begin a match of that rule.
+type FirstSet map[string]RuneSet
+
+// FollowSet maps each rule name to the set of terminal runes that can
+// immediately follow a match of that rule, in every context the
+// grammar calls it from.
+type FollowSet map[string]RuneSet
+
+// Nullable maps each rule name to whether it can match the empty
+// string.
+type Nullable map[string]bool
+
+// ComputeFirstFollow runs the classic two fixed-point passes over a
+// grammar: FIRST/nullability first (since FOLLOW's propagation needs
+// to know whether a sub-expression can vanish), then FOLLOW. The
+// result drives GenGoOptions.Recovery's synchronization sets: on a
+// labeled failure, the generated parser consumes input until it sees
+// a rune in FOLLOW(enclosing rule), which is the best local guess at
+// "where parsing could plausibly resume".
+func ComputeFirstFollow(g *GrammarNode) (FirstSet, FollowSet, Nullable) {
+	first, nullable := computeFirstNullable(g)
+	follow := computeFollow(g, first, nullable)
+	return first, follow, nullable
+}
+
+func computeFirstNullable(g *GrammarNode) (FirstSet, Nullable) {
+	first := make(FirstSet, len(g.Definitions))
+	nullable := make(Nullable, len(g.Definitions))
+	for _, def := range g.Definitions {
+		first[def.Name] = RuneSet{}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, def := range g.Definitions {
+			set, isNullable := firstOf(def.Expr, first, nullable)
+			if first[def.Name].merge(set) {
+				changed = true
+			}
+			if isNullable && !nullable[def.Name] {
+				nullable[def.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	return first, nullable
+}
+
+// firstOf computes the FIRST set and nullability of a single
+// expression node, consulting (but never mutating) the in-progress
+// first/nullable tables for IdentifierNode references.
+func firstOf(node Node, first FirstSet, nullable Nullable) (RuneSet, bool) {
+	switch n := node.(type) {
+	case *AnyNode:
+		return RuneSet{}, false
+
+	case *LiteralNode:
+		runes := []rune(n.Value)
+		if len(runes) == 0 {
+			return RuneSet{}, true
+		}
+		return newRuneSet(runes[0]), false
+
+	case *RangeNode:
+		lo, hi := []rune(n.Left)[0], []rune(n.Right)[0]
+		set := RuneSet{}
+		for r := lo; r <= hi; r++ {
+			set[r] = struct{}{}
+		}
+		return set, false
+
+	case *ClassNode:
+		set := RuneSet{}
+		isNullable := false
+		for _, item := range n.Items {
+			itemSet, itemNullable := firstOf(item, first, nullable)
+			set.merge(itemSet)
+			isNullable = isNullable || itemNullable
+		}
+		return set, isNullable
+
+	case *IdentifierNode:
+		return first[n.Value], nullable[n.Value]
+
+	case *OptionalNode:
+		set, _ := firstOf(n.Expr, first, nullable)
+		return set, true
+
+	case *ZeroOrMoreNode:
+		set, _ := firstOf(n.Expr, first, nullable)
+		return set, true
+
+	case *OneOrMoreNode:
+		return firstOf(n.Expr, first, nullable)
+
+	case *AndNode, *NotNode:
+		return RuneSet{}, true
+
+	case *LexNode:
+		return firstOf(n.Expr, first, nullable)
+
+	case *LabeledNode:
+		return firstOf(n.Expr, first, nullable)
+
+	case *SequenceNode:
+		set := RuneSet{}
+		seqNullable := true
+		for _, item := range n.Items {
+			itemSet, itemNullable := firstOf(item, first, nullable)
+			set.merge(itemSet)
+			if !itemNullable {
+				seqNullable = false
+				break
+			}
+		}
+		return set, seqNullable
+
+	case *ChoiceNode:
+		set := RuneSet{}
+		isNullable := false
+		for _, item := range n.Items {
+			itemSet, itemNullable := firstOf(item, first, nullable)
+			set.merge(itemSet)
+			isNullable = isNullable || itemNullable
+		}
+		return set, isNullable
+
+	default:
+		return RuneSet{}, true
+	}
+}
+
+func computeFollow(g *GrammarNode, first FirstSet, nullable Nullable) FollowSet {
+	follow := make(FollowSet, len(g.Definitions))
+	for _, def := range g.Definitions {
+		follow[def.Name] = RuneSet{}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, def := range g.Definitions {
+			contributions := make(FollowSet)
+			propagateFollow(def.Expr, follow[def.Name], true, first, nullable, contributions)
+			for name, set := range contributions {
+				if follow[name] == nil {
+					follow[name] = RuneSet{}
+				}
+				if follow[name].merge(set) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	return follow
+}
+
+// propagateFollow walks node right-to-left-equivalent, threading
+// "tail" (what can come immediately after node) and "tailNullable"
+// (whether everything in tail could itself vanish, letting whatever
+// follows tail apply too) down to every IdentifierNode it finds,
+// recording contributions to that rule's FOLLOW set.
+func propagateFollow(node Node, tail RuneSet, tailNullable bool, first FirstSet, nullable Nullable, acc FollowSet) {
+	switch n := node.(type) {
+	case *IdentifierNode:
+		set := acc[n.Value]
+		if set == nil {
+			set = RuneSet{}
+			acc[n.Value] = set
+		}
+		set.merge(tail)
+
+	case *SequenceNode:
+		trailer, trailerNullable := tail, tailNullable
+		for i := len(n.Items) - 1; i >= 0; i-- {
+			propagateFollow(n.Items[i], trailer, trailerNullable, first, nullable, acc)
+
+			itemFirst, itemNullable := firstOf(n.Items[i], first, nullable)
+			next := RuneSet{}
+			next.merge(itemFirst)
+			if itemNullable {
+				next.merge(trailer)
+			}
+			trailer = next
+			trailerNullable = itemNullable && trailerNullable
+		}
+
+	case *ChoiceNode:
+		for _, item := range n.Items {
+			propagateFollow(item, tail, tailNullable, first, nullable, acc)
+		}
+
+	case *OptionalNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *ZeroOrMoreNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *OneOrMoreNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *AndNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *NotNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *LexNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	case *LabeledNode:
+		propagateFollow(n.Expr, tail, tailNullable, first, nullable, acc)
+	}
+}
@@ -0,0 +1,80 @@
+package parsing
+
+import "testing"
+
+// grammar is a small test helper that wires up a GrammarNode by hand,
+// the same shape a parsed .peg file would produce, without needing a
+// bootstrapping parser in this test binary.
+func grammar(defs ...*DefinitionNode) *GrammarNode {
+	byName := make(map[string]*DefinitionNode, len(defs))
+	for _, d := range defs {
+		byName[d.Name] = d
+	}
+	return NewGrammarNode(nil, defs, byName, Span{})
+}
+
+func rule(name string, expr Node) *DefinitionNode {
+	return NewDefinitionNode(name, expr, Span{})
+}
+
+func id(name string) Node { return NewIdentifierNode(name, Span{}) }
+func lit(s string) Node   { return NewLiteralNode(s, Span{}) }
+
+func TestComputeFirstFollow(t *testing.T) {
+	// S <- A B
+	// A <- 'a'
+	// B <- 'b'
+	g := grammar(
+		rule("S", NewSequenceNode([]Node{id("A"), id("B")}, Span{})),
+		rule("A", lit("a")),
+		rule("B", lit("b")),
+	)
+
+	first, follow, nullable := ComputeFirstFollow(g)
+
+	for name, want := range map[string]rune{"S": 'a', "A": 'a', "B": 'b'} {
+		set := first[name]
+		if len(set) != 1 {
+			t.Fatalf("FIRST(%s) = %v, want exactly {%q}", name, set.sorted(), want)
+		}
+		if _, ok := set[want]; !ok {
+			t.Errorf("FIRST(%s) = %v, want {%q}", name, set.sorted(), want)
+		}
+	}
+
+	if _, ok := follow["A"]['b']; !ok || len(follow["A"]) != 1 {
+		t.Errorf("FOLLOW(A) = %v, want {'b'}", follow["A"].sorted())
+	}
+	if len(follow["B"]) != 0 {
+		t.Errorf("FOLLOW(B) = %v, want empty (nothing follows B in S)", follow["B"].sorted())
+	}
+
+	for name := range g.DefsByName {
+		if nullable[name] {
+			t.Errorf("nullable[%s] = true, want false", name)
+		}
+	}
+}
+
+func TestComputeFirstFollowNullable(t *testing.T) {
+	// S <- A 'x'
+	// A <- 'a'?
+	g := grammar(
+		rule("S", NewSequenceNode([]Node{id("A"), lit("x")}, Span{})),
+		rule("A", NewOptionalNode(lit("a"), Span{})),
+	)
+
+	first, follow, nullable := ComputeFirstFollow(g)
+
+	if !nullable["A"] {
+		t.Error("nullable[A] = false, want true (it's wrapped in '?')")
+	}
+	if _, ok := first["A"]['a']; !ok || len(first["A"]) != 1 {
+		t.Errorf("FIRST(A) = %v, want {'a'}", first["A"].sorted())
+	}
+	// Even though A is nullable, 'x' is the only thing that can
+	// follow it here since the only sequence containing A is S itself.
+	if _, ok := follow["A"]['x']; !ok || len(follow["A"]) != 1 {
+		t.Errorf("FOLLOW(A) = %v, want {'x'}", follow["A"].sorted())
+	}
+}
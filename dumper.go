@@ -0,0 +1,159 @@
+package parsing
+
+import (
+	"fmt"
+	"io"
+)
+
+// Dumper writes an indented, position-annotated tree of any AstNode,
+// the same way cmd/compile/internal/syntax's dumper.go renders Go's
+// own AST for debugging. It walks the tree through Accept rather than
+// each node's ad-hoc String() method, so adding a node type only
+// requires teaching Dumper (and the AstNodeVisitor it implements)
+// about it once, instead of every caller that wants a readable tree.
+type Dumper struct {
+	out   io.Writer
+	depth int
+	err   error
+}
+
+// NewDumper returns a Dumper that writes to out.
+func NewDumper(out io.Writer) *Dumper {
+	return &Dumper{out: out}
+}
+
+// Dump writes node and everything beneath it to d's writer.
+func (d *Dumper) Dump(node AstNode) error {
+	d.err = nil
+	_ = node.Accept(d)
+	return d.err
+}
+
+func (d *Dumper) line(format string, args ...any) {
+	if d.err != nil {
+		return
+	}
+	for i := 0; i < d.depth; i++ {
+		if _, err := io.WriteString(d.out, "  "); err != nil {
+			d.err = err
+			return
+		}
+	}
+	if _, err := fmt.Fprintf(d.out, format, args...); err != nil {
+		d.err = err
+		return
+	}
+	if _, err := io.WriteString(d.out, "\n"); err != nil {
+		d.err = err
+	}
+}
+
+func (d *Dumper) visitChildren(children ...AstNode) {
+	d.depth++
+	for _, child := range children {
+		if d.err != nil {
+			break
+		}
+		if err := child.Accept(d); err != nil {
+			d.err = err
+		}
+	}
+	d.depth--
+}
+
+func (d *Dumper) VisitAnyNode(n *AnyNode) error {
+	d.line("Any @ %s", n.Span())
+	return d.err
+}
+
+func (d *Dumper) VisitLiteralNode(n *LiteralNode) error {
+	d.line("Literal(%q) @ %s", n.Value, n.Span())
+	return d.err
+}
+
+func (d *Dumper) VisitIdentifierNode(n *IdentifierNode) error {
+	d.line("Identifier(%s) @ %s", n.Value, n.Span())
+	return d.err
+}
+
+func (d *Dumper) VisitRangeNode(n *RangeNode) error {
+	d.line("Range(%s-%s) @ %s", n.Left, n.Right, n.Span())
+	return d.err
+}
+
+func (d *Dumper) VisitClassNode(n *ClassNode) error {
+	d.line("Class @ %s", n.Span())
+	d.visitChildren(n.Items...)
+	return d.err
+}
+
+func (d *Dumper) VisitOptionalNode(n *OptionalNode) error {
+	d.line("Optional @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitZeroOrMoreNode(n *ZeroOrMoreNode) error {
+	d.line("ZeroOrMore @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitOneOrMoreNode(n *OneOrMoreNode) error {
+	d.line("OneOrMore @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitAndNode(n *AndNode) error {
+	d.line("And @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitNotNode(n *NotNode) error {
+	d.line("Not @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitLexNode(n *LexNode) error {
+	d.line("Lex @ %s", n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitLabeledNode(n *LabeledNode) error {
+	d.line("Label(%s) @ %s", n.Label, n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitSequenceNode(n *SequenceNode) error {
+	d.line("Sequence @ %s", n.Span())
+	d.visitChildren(n.Items...)
+	return d.err
+}
+
+func (d *Dumper) VisitChoiceNode(n *ChoiceNode) error {
+	d.line("Choice @ %s", n.Span())
+	d.visitChildren(n.Items...)
+	return d.err
+}
+
+func (d *Dumper) VisitDefinitionNode(n *DefinitionNode) error {
+	d.line("Definition(%s) @ %s", n.Name, n.Span())
+	d.visitChildren(n.Expr)
+	return d.err
+}
+
+func (d *Dumper) VisitImportNode(n *ImportNode) error {
+	d.line("Import(%s) @ %s", n.GetPath(), n.Span())
+	return d.err
+}
+
+func (d *Dumper) VisitGrammarNode(n *GrammarNode) error {
+	d.line("Grammar @ %s", n.Span())
+	d.visitChildren(n.GetItems()...)
+	return d.err
+}
@@ -0,0 +1,46 @@
+package parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumperIndentsNestedChildren(t *testing.T) {
+	n := NewSequenceNode([]Node{lit("a"), lit("b")}, Span{})
+
+	var out strings.Builder
+	if err := NewDumper(&out).Dump(n); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (Sequence + 2 Literal children):\n%s", len(lines), out.String())
+	}
+	if strings.HasPrefix(lines[0], " ") {
+		t.Errorf("root line %q is indented, want no leading indent", lines[0])
+	}
+	for _, child := range lines[1:] {
+		if !strings.HasPrefix(child, "  ") {
+			t.Errorf("child line %q isn't indented one level under its parent", child)
+		}
+	}
+	if !strings.Contains(lines[0], "Sequence") {
+		t.Errorf("root line = %q, want it to mention Sequence", lines[0])
+	}
+	if !strings.Contains(out.String(), `Literal("a")`) || !strings.Contains(out.String(), `Literal("b")`) {
+		t.Errorf("expected both literal children rendered, got:\n%s", out.String())
+	}
+}
+
+func TestDumperDefinitionNamesTheRule(t *testing.T) {
+	n := rule("S", lit("a"))
+
+	var out strings.Builder
+	if err := NewDumper(&out).Dump(n); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if !strings.Contains(out.String(), "Definition(S)") {
+		t.Errorf("expected the definition's name in the dump, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,35 @@
+package parsing
+
+// codeEmitter is implemented by every text-based code-generation
+// backend (the Go and Rust emitters today).  They all share the same
+// life cycle: construct, writeHeader, walk the grammar with visit,
+// writeFooter, then read back the accumulated output with String.
+// GenGo and GenRust each drive their own emitter through that life
+// cycle; the assertions below check that both emitters implement the
+// interface, but no Gen* function takes a codeEmitter today.
+//
+// The bytecode target (GenVM) does not implement this interface: its
+// output is a []Instr/SourceMap pair rather than a string, so it gets
+// its own compiler type instead of a third string-producing emitter.
+type codeEmitter interface {
+	// writeHeader emits whatever preamble the target needs (package
+	// clause and imports, a module prologue, etc) before any rule
+	// in the grammar is visited.
+	writeHeader()
+
+	// visit walks a single AST node, dispatching to the emitter's
+	// own Visit* methods and appending to its output buffer.
+	visit(Node)
+
+	// writeFooter emits whatever the target needs once every
+	// definition in the grammar has been visited.
+	writeFooter()
+
+	// String returns the source code accumulated so far.
+	String() string
+}
+
+var (
+	_ codeEmitter = (*goCodeEmitter)(nil)
+	_ codeEmitter = (*rustCodeEmitter)(nil)
+)
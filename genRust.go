@@ -0,0 +1,361 @@
+package parsing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rustCodeEmitter is the Rust sibling of goCodeEmitter: it walks the
+// same GrammarNode and produces a parser that leans on the runtime
+// primitives exposed by the `langlang` crate (the Rust counterpart of
+// the `github.com/clarete/langlang/go` package), instead of hand
+// writing a recursive-descent parser from scratch.
+type rustCodeEmitter struct {
+	options     GenRustOptions
+	output      *strings.Builder
+	indentLevel int
+}
+
+type GenRustOptions struct {
+	// ModuleName is the Rust module the generated parser is placed
+	// in (e.g. `mod <ModuleName> { ... }`).
+	ModuleName string
+
+	// StructName is the name given to the generated parser struct.
+	// Mirrors GenGoOptions.StructSuffix, except Rust has no need to
+	// disambiguate with a shared "Parser" prefix, so it's the full
+	// name rather than a suffix.
+	StructName string
+}
+
+func DefaultGenRustOptions() GenRustOptions {
+	return GenRustOptions{
+		ModuleName: "parser",
+		StructName: "Parser",
+	}
+}
+
+func newRustCodeEmitter(opt GenRustOptions) *rustCodeEmitter {
+	return &rustCodeEmitter{options: opt, output: &strings.Builder{}}
+}
+
+func (g *rustCodeEmitter) writeHeader() {
+	fmt.Fprintf(g.output, `use langlang::{BaseParser, Parser as _, Span, Value};
+
+pub mod %s {
+    use super::*;
+
+    pub struct %s {
+        base: BaseParser,
+    }
+
+    impl %s {
+        pub fn new(input: &str) -> Self {
+            Self { base: BaseParser::new(input.chars().collect()) }
+        }
+
+        pub fn parse_any(&mut self) -> Result<Value, langlang::Error> {
+            let start = self.base.location();
+            let r = self.base.any()?;
+            Ok(Value::string(r.to_string(), Span::new(start, self.base.location())))
+        }
+
+        pub fn parse_range(&mut self, left: char, right: char) -> Result<Value, langlang::Error> {
+            let start = self.base.location();
+            let r = self.base.expect_range(left, right)?;
+            Ok(Value::string(r.to_string(), Span::new(start, self.base.location())))
+        }
+
+        pub fn parse_literal(&mut self, literal: &str) -> Result<Value, langlang::Error> {
+            let start = self.base.location();
+            let r = self.base.expect_literal(literal)?;
+            Ok(Value::string(r, Span::new(start, self.base.location())))
+        }
+
+        pub fn parse_spacing(&mut self) -> Result<Value, langlang::Error> {
+            let start = self.base.location();
+            let v = langlang::zero_or_more(self, |p| {
+                langlang::choice_char(p, &[' ', '\t', '\r', '\n'])
+            })?;
+            Ok(Value::string(v.into_iter().collect(), Span::new(start, self.base.location())))
+        }
+
+        pub fn parse_eof(&mut self) -> Result<Value, langlang::Error> {
+            let start = self.base.location();
+            let mut items = vec![];
+            items.push(langlang::not(self, |p| p.parse_any())?);
+            Ok(Value::sequence(items, Span::new(start, self.base.location())))
+        }
+`, g.options.ModuleName, g.options.StructName, g.options.StructName)
+}
+
+// writeFooter closes the struct impl block and module opened by
+// writeHeader.
+func (g *rustCodeEmitter) writeFooter() {
+	g.write("    }\n}\n")
+}
+
+func (g *rustCodeEmitter) visit(node Node) {
+	switch n := node.(type) {
+	case *GrammarNode:
+		g.visitGrammarNode(n)
+	case *DefinitionNode:
+		g.visitDefinitionNode(n)
+	case *SequenceNode:
+		g.visitSequenceNode(n)
+	case *OneOrMoreNode:
+		g.visitOneOrMoreNode(n)
+	case *ZeroOrMoreNode:
+		g.visitZeroOrMoreNode(n)
+	case *OptionalNode:
+		g.visitOptionalNode(n)
+	case *ChoiceNode:
+		g.visitChoiceNode(n)
+	case *AndNode:
+		g.visitAndNode(n)
+	case *NotNode:
+		g.visitNotNode(n)
+	case *LabeledNode:
+		g.visitLabeledNode(n)
+	case *IdentifierNode:
+		g.visitIdentifierNode(n)
+	case *LiteralNode:
+		g.visitLiteralNode(n)
+	case *ClassNode:
+		g.visitClassNode(n)
+	case *RangeNode:
+		g.visitRangeNode(n)
+	case *AnyNode:
+		g.visitAnyNode()
+	}
+}
+
+func (g *rustCodeEmitter) visitGrammarNode(n *GrammarNode) {
+	for _, definition := range n.Items {
+		g.visit(definition)
+	}
+}
+
+func (g *rustCodeEmitter) visitDefinitionNode(n *DefinitionNode) {
+	g.write("\n")
+	g.writei(fmt.Sprintf("pub fn parse_%s(&mut self) -> Result<Value, langlang::Error> {\n", toSnakeCase(n.Name)))
+	g.indent()
+	g.writei("self.base.push_trace_span(")
+	fmt.Fprintf(g.output, `langlang::TracerSpan::new(%q)`, n.Name)
+	g.write(");\n")
+	g.writei("let result = (|| ")
+	g.visit(n.Expr)
+	g.write(")();\n")
+	g.writei("self.base.pop_trace_span();\n")
+	g.writei("result\n")
+	g.unindent()
+	g.writei("}\n")
+}
+
+func (g *rustCodeEmitter) visitSequenceNode(n *SequenceNode) {
+	shouldConsumeSpaces := g.isUnderRuleLevel() && !n.IsSyntactic()
+	g.write("{\n")
+	g.indent()
+	g.writei("let start = self.base.location();\n")
+	g.writei("let mut items: Vec<Value> = vec![];\n")
+
+	for _, item := range n.Items {
+		if shouldConsumeSpaces {
+			g.writei("items.push(self.parse_spacing()?);\n")
+		}
+		g.writei("items.push(")
+		g.visit(item)
+		g.write("?);\n")
+	}
+
+	g.writei("Ok(Value::sequence(items, Span::new(start, self.base.location())))\n")
+	g.unindent()
+	g.writei("}")
+}
+
+func (g *rustCodeEmitter) visitOneOrMoreNode(n *OneOrMoreNode) {
+	g.write("{\n")
+	g.indent()
+	g.writei("let start = self.base.location();\n")
+	g.writei("let items = langlang::one_or_more(self, |p| ")
+	g.visit(n.Expr)
+	g.write(")?;\n")
+	g.writei("Ok(Value::sequence(items, Span::new(start, self.base.location())))\n")
+	g.unindent()
+	g.writei("}")
+}
+
+func (g *rustCodeEmitter) visitZeroOrMoreNode(n *ZeroOrMoreNode) {
+	g.write("{\n")
+	g.indent()
+	g.writei("let start = self.base.location();\n")
+	g.writei("let items = langlang::zero_or_more(self, |p| ")
+	g.visit(n.Expr)
+	g.write(")?;\n")
+	g.writei("Ok(Value::sequence(items, Span::new(start, self.base.location())))\n")
+	g.unindent()
+	g.writei("}")
+}
+
+func (g *rustCodeEmitter) visitOptionalNode(n *OptionalNode) {
+	g.write("langlang::choice(self, &[\n")
+	g.indent()
+	g.writeExprFn(n.Expr)
+	g.write(",\n")
+	// The no-match arm has to return the same Result<Value, Error> as
+	// the expression arm above it -- Value isn't nilable the way the
+	// Go backend's interface is, so there's no `None` to fall back
+	// to. An empty sequence at the current position is this backend's
+	// stand-in for "matched nothing", the same role Go's `nil` plays.
+	g.writei("&|p| Ok(Value::sequence(vec![], Span::new(p.base.location(), p.base.location()))),\n")
+	g.unindent()
+	g.writei("])")
+}
+
+func (g *rustCodeEmitter) visitChoiceNode(n *ChoiceNode) {
+	switch len(n.Items) {
+	case 0:
+		return
+	case 1:
+		g.visit(n.Items[0])
+	default:
+		g.write("langlang::choice(self, &[\n")
+		g.indent()
+		for _, expr := range n.Items {
+			g.writeExprFn(expr)
+			g.write(",\n")
+		}
+		g.unindent()
+		g.writei("])")
+	}
+}
+
+func (g *rustCodeEmitter) visitAndNode(n *AndNode) {
+	g.write("langlang::and(self, |p| ")
+	g.visit(n.Expr)
+	g.write(")")
+}
+
+func (g *rustCodeEmitter) visitNotNode(n *NotNode) {
+	g.write("langlang::not(self, |p| ")
+	g.visit(n.Expr)
+	g.write(")")
+}
+
+func (g *rustCodeEmitter) visitLabeledNode(n *LabeledNode) {
+	g.write("{\n")
+	g.indent()
+	g.writei("let start = self.base.location();\n")
+	g.writei("langlang::choice(self, &[\n")
+	g.indent()
+	g.writeExprFn(n.Expr)
+	g.write(",\n")
+	g.writei(fmt.Sprintf("&|p| Err(p.throw(%q, Span::new(start, p.base.location()))),\n", n.Label))
+	g.unindent()
+	g.writei("])\n")
+	g.unindent()
+	g.writei("}")
+}
+
+func (g *rustCodeEmitter) visitIdentifierNode(n *IdentifierNode) {
+	g.write(fmt.Sprintf("p.parse_%s()", toSnakeCase(n.Value)))
+}
+
+func (g *rustCodeEmitter) visitLiteralNode(n *LiteralNode) {
+	g.write(fmt.Sprintf("p.parse_literal(%q)", n.Value))
+}
+
+func (g *rustCodeEmitter) visitClassNode(n *ClassNode) {
+	switch len(n.Items) {
+	case 0:
+	case 1:
+		g.visit(n.Items[0])
+	default:
+		g.write("langlang::choice(self, &[\n")
+		g.indent()
+		for _, expr := range n.Items {
+			g.writeExprFn(expr)
+			g.write(",\n")
+		}
+		g.unindent()
+		g.writei("])")
+	}
+}
+
+func (g *rustCodeEmitter) visitRangeNode(n *RangeNode) {
+	g.write(fmt.Sprintf("p.parse_range(%q, %q)", n.Left, n.Right))
+}
+
+func (g *rustCodeEmitter) visitAnyNode() {
+	g.write("p.parse_any()")
+}
+
+// Utilities to write data into the output buffer; these mirror
+// goCodeEmitter's writei/indent/unindent helpers, since the two
+// emitters share the same traversal shape, but indent with four
+// spaces and write text without any $StructSuffix substitution.
+
+func (g *rustCodeEmitter) writeExprFn(expr Node) {
+	g.writei("&|p| ")
+	g.visit(expr)
+}
+
+func (g *rustCodeEmitter) writei(s string) {
+	g.writeIndent()
+	g.write(s)
+}
+
+func (g *rustCodeEmitter) write(s string) {
+	g.output.WriteString(s)
+}
+
+func (g *rustCodeEmitter) writeIndent() {
+	for i := 0; i < g.indentLevel; i++ {
+		g.output.WriteString("    ")
+	}
+}
+
+func (g *rustCodeEmitter) indent() {
+	g.indentLevel++
+}
+
+func (g *rustCodeEmitter) unindent() {
+	g.indentLevel--
+}
+
+func (g *rustCodeEmitter) isUnderRuleLevel() bool {
+	return g.indentLevel >= 1
+}
+
+func (g *rustCodeEmitter) String() string {
+	return g.output.String()
+}
+
+// toSnakeCase converts a PascalCase/camelCase grammar rule name (the
+// convention used throughout the .peg grammars) into the snake_case
+// convention expected of Rust method names.
+func toSnakeCase(s string) string {
+	var out strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				out.WriteByte('_')
+			}
+			out.WriteRune(r - 'A' + 'a')
+		} else {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// GenRust compiles the given grammar into a Rust module that uses the
+// `langlang` crate's parser combinators, the same way GenGo compiles
+// it into a Go package built on top of parsing.BaseParser.
+func GenRust(node Node, opt GenRustOptions) (string, error) {
+	g := newRustCodeEmitter(opt)
+	g.writeHeader()
+	g.visit(node)
+	g.writeFooter()
+	return g.String(), nil
+}
@@ -0,0 +1,46 @@
+package parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenRustSequencePushesItemsDirectly(t *testing.T) {
+	g := grammar(rule("S", NewSequenceNode([]Node{lit("a"), lit("b")}, Span{})))
+
+	out, err := GenRust(g, DefaultGenRustOptions())
+	if err != nil {
+		t.Fatalf("GenRust: %v", err)
+	}
+	if strings.Contains(out, "if let Some(item)") {
+		t.Errorf("generated Rust still guards item pushes with an Option pattern that doesn't type-check against Result<Value, Error>:\n%s", out)
+	}
+	if !strings.Contains(out, "items.push(") {
+		t.Errorf("expected sequence items to be pushed directly, got:\n%s", out)
+	}
+}
+
+func TestGenRustOptionalArmsShareType(t *testing.T) {
+	g := grammar(rule("S", NewOptionalNode(lit("a"), Span{})))
+
+	out, err := GenRust(g, DefaultGenRustOptions())
+	if err != nil {
+		t.Fatalf("GenRust: %v", err)
+	}
+	if strings.Contains(out, "Ok(None)") {
+		t.Errorf("optional no-match arm still returns Ok(None), incompatible with the expression arm's Result<Value, Error>:\n%s", out)
+	}
+	if !strings.Contains(out, "Value::sequence(vec![]") {
+		t.Errorf("expected the no-match arm to return an empty-sequence Value instead, got:\n%s", out)
+	}
+}
+
+func TestGenRustParseEOFPushesDirectly(t *testing.T) {
+	out, err := GenRust(grammar(rule("S", lit("a"))), DefaultGenRustOptions())
+	if err != nil {
+		t.Fatalf("GenRust: %v", err)
+	}
+	if !strings.Contains(out, "items.push(langlang::not(self, |p| p.parse_any())?);") {
+		t.Errorf("expected parse_eof to push the not-predicate result directly, got:\n%s", out)
+	}
+}
@@ -0,0 +1,350 @@
+package parsing
+
+import "fmt"
+
+// Op identifies a single instruction of the parsing machine that
+// GenVM compiles grammars down to.  The instruction set models the
+// abstract machine described by Medeiros & Ierusalimschy for PEGs:
+// every combinator (sequence, choice, repetition, predicates) reduces
+// to these twelve opcodes, with OpCall/OpReturn linking rule entry
+// points.
+type Op int
+
+const (
+	// OpChar matches a single literal rune, advancing on success.
+	OpChar Op = iota
+	// OpAny matches and consumes any single rune.
+	OpAny
+	// OpRange matches a rune within [Lo, Hi], advancing on success.
+	OpRange
+	// OpChoice pushes a backtrack point at Arg (the alternative to
+	// try if everything up to the matching OpCommit fails).
+	OpChoice
+	// OpCommit discards the backtrack point pushed by the matching
+	// OpChoice and jumps to Arg.
+	OpCommit
+	// OpCall pushes a return address and jumps to the entry point
+	// of the rule named in Rule.
+	OpCall
+	// OpReturn pops the return address pushed by OpCall and jumps
+	// back to it.
+	OpReturn
+	// OpFail triggers backtracking to the most recent choice point,
+	// or fails the whole parse if none remain.
+	OpFail
+	// OpFailTwice discards the most recent choice point without
+	// backtracking to it, then fails as OpFail would against whatever
+	// choice point is left underneath. This is how a predicate's own
+	// OpChoice frame gets retired when its guarded expression
+	// succeeds: the predicate still has to fail overall, but it must
+	// not resume at the position *that* frame saved -- it needs to
+	// propagate the failure past itself, to whatever choice point (if
+	// any) exists further up the stack.
+	OpFailTwice
+	// OpThrow raises a labeled error (from a LabeledNode) that
+	// fails the parse unless the caller installs its own recovery.
+	OpThrow
+	// OpSpanOpen records the current cursor position as the start
+	// of the value span being built.
+	OpSpanOpen
+	// OpSpanClose closes the span opened by the matching
+	// OpSpanOpen and emits a value node covering it.
+	OpSpanClose
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpChar:
+		return "Char"
+	case OpAny:
+		return "Any"
+	case OpRange:
+		return "Range"
+	case OpChoice:
+		return "Choice"
+	case OpCommit:
+		return "Commit"
+	case OpCall:
+		return "Call"
+	case OpReturn:
+		return "Return"
+	case OpFail:
+		return "Fail"
+	case OpFailTwice:
+		return "FailTwice"
+	case OpThrow:
+		return "Throw"
+	case OpSpanOpen:
+		return "SpanOpen"
+	case OpSpanClose:
+		return "SpanClose"
+	default:
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+}
+
+// Instr is a single instruction in the compiled instruction stream.
+// Only the fields relevant to Op are populated; the rest are left at
+// their zero value.
+type Instr struct {
+	Op Op
+
+	// Char is the rune matched by OpChar.
+	Char rune
+
+	// Lo and Hi bound the rune matched by OpRange.
+	Lo, Hi rune
+
+	// Arg is a relative instruction offset used by OpChoice and
+	// OpCommit to locate the alternative/target instruction, and by
+	// OpCall (once resolveCalls has patched it) to locate the
+	// called rule's entry point.
+	Arg int
+
+	// Rule is the name of the definition OpCall jumps into.
+	Rule string
+
+	// Label is the error label raised by OpThrow.
+	Label string
+}
+
+// SourceMap maps an instruction pointer back to the grammar Span it
+// was compiled from, the same way bytecode VMs keep a per-ip line
+// table so that runtime failures can be reported against source
+// positions instead of raw offsets.
+type SourceMap map[int]Span
+
+type GenVMOptions struct {
+	// EntryRule names the definition GenVM's returned entry point
+	// should resolve to. Left empty, entry is the first definition
+	// compiled (instruction 0), the same as a generated Go/Rust
+	// parser always starting from the grammar's first rule.
+	EntryRule string
+}
+
+func DefaultGenVMOptions() GenVMOptions {
+	return GenVMOptions{}
+}
+
+// vmCompiler walks a GrammarNode and emits a flat Instr stream plus
+// the SourceMap describing where each instruction came from. Unlike
+// goCodeEmitter/rustCodeEmitter it doesn't implement codeEmitter: its
+// output is a program, not source text, so the two life cycles don't
+// share a useful interface.
+type vmCompiler struct {
+	options   GenVMOptions
+	program   []Instr
+	sourceMap SourceMap
+	// entryPoints records the instruction pointer each rule's body
+	// starts at, resolved once every definition has been emitted so
+	// OpCall can be patched with a concrete jump target.
+	entryPoints map[string]int
+	// pendingCalls remembers the index of every OpCall instruction,
+	// since its target rule may not have been compiled yet, so Rule
+	// can be resolved into Arg in a final patch-up pass.
+	pendingCalls []int
+}
+
+func newVMCompiler(opt GenVMOptions) *vmCompiler {
+	return &vmCompiler{
+		options:     opt,
+		sourceMap:   SourceMap{},
+		entryPoints: map[string]int{},
+	}
+}
+
+func (c *vmCompiler) emit(span Span, instr Instr) int {
+	ip := len(c.program)
+	c.program = append(c.program, instr)
+	c.sourceMap[ip] = span
+	return ip
+}
+
+func (c *vmCompiler) compile(node Node) {
+	switch n := node.(type) {
+	case *GrammarNode:
+		c.compileGrammarNode(n)
+	case *DefinitionNode:
+		c.compileDefinitionNode(n)
+	case *SequenceNode:
+		for _, item := range n.Items {
+			c.compile(item)
+		}
+	case *ChoiceNode:
+		c.compileChoiceNode(n)
+	case *OneOrMoreNode:
+		c.compile(n.Expr)
+		c.compileZeroOrMore(n.Expr)
+	case *ZeroOrMoreNode:
+		c.compileZeroOrMore(n.Expr)
+	case *OptionalNode:
+		c.compileOptionalNode(n)
+	case *AndNode:
+		c.compileAndNode(n)
+	case *NotNode:
+		c.compileNotNode(n)
+	case *LabeledNode:
+		c.compileLabeledNode(n)
+	case *IdentifierNode:
+		c.compileIdentifierNode(n)
+	case *LiteralNode:
+		c.compileLiteralNode(n)
+	case *ClassNode:
+		c.compileChoiceOfItems(n.Items, n.Span())
+	case *RangeNode:
+		c.compileRangeNode(n)
+	case *AnyNode:
+		c.emit(n.Span(), Instr{Op: OpAny})
+	}
+}
+
+func (c *vmCompiler) compileGrammarNode(n *GrammarNode) {
+	for _, def := range n.Definitions {
+		c.compileDefinitionNode(def)
+	}
+	c.resolveCalls()
+}
+
+func (c *vmCompiler) compileDefinitionNode(n *DefinitionNode) {
+	c.entryPoints[n.Name] = len(c.program)
+	c.emit(n.Span(), Instr{Op: OpSpanOpen})
+	c.compile(n.Expr)
+	c.emit(n.Span(), Instr{Op: OpSpanClose})
+	c.emit(n.Span(), Instr{Op: OpReturn})
+}
+
+func (c *vmCompiler) compileChoiceNode(n *ChoiceNode) {
+	c.compileChoiceOfItems(n.Items, n.Span())
+}
+
+// compileChoiceOfItems emits the classic Choice/Commit bracketing for
+// an ordered list of alternatives: every alternative but the last is
+// preceded by a Choice pointing past it (to the next alternative) and
+// followed by a Commit past the whole construct; the last alternative
+// is emitted bare since there's nothing left to fall back to.
+func (c *vmCompiler) compileChoiceOfItems(items []Node, span Span) {
+	if len(items) == 0 {
+		return
+	}
+	if len(items) == 1 {
+		c.compile(items[0])
+		return
+	}
+
+	var commits []int
+	for _, item := range items[:len(items)-1] {
+		choiceIP := c.emit(span, Instr{Op: OpChoice})
+		c.compile(item)
+		commitIP := c.emit(span, Instr{Op: OpCommit})
+		commits = append(commits, commitIP)
+		c.program[choiceIP].Arg = len(c.program) - choiceIP
+	}
+	c.compile(items[len(items)-1])
+	end := len(c.program)
+	for _, ip := range commits {
+		c.program[ip].Arg = end - ip
+	}
+}
+
+// compileZeroOrMore emits `L1: Choice L2; <expr>; Commit L1; L2:`
+// which greedily repeats expr until it fails, then falls through.
+func (c *vmCompiler) compileZeroOrMore(expr Node) {
+	span := expr.Span()
+	choiceIP := c.emit(span, Instr{Op: OpChoice})
+	c.compile(expr)
+	c.emit(span, Instr{Op: OpCommit, Arg: choiceIP - len(c.program)})
+	c.program[choiceIP].Arg = len(c.program) - choiceIP
+}
+
+func (c *vmCompiler) compileOptionalNode(n *OptionalNode) {
+	span := n.Span()
+	choiceIP := c.emit(span, Instr{Op: OpChoice})
+	c.compile(n.Expr)
+	commitIP := c.emit(span, Instr{Op: OpCommit})
+	c.program[choiceIP].Arg = len(c.program) - choiceIP
+	c.program[commitIP].Arg = len(c.program) - commitIP
+}
+
+// compileAndNode implements the `&e` predicate as `!!e`: Not twice,
+// since the instruction set doesn't need a dedicated opcode for it.
+func (c *vmCompiler) compileAndNode(n *AndNode) {
+	c.compileNotNode(NewNotNode(NewNotNode(n.Expr, n.Span()), n.Span()))
+}
+
+// compileNotNode emits `Choice L1; <e>; FailTwice; L1:`. If e fails,
+// the pushed choice point backtracks here, landing past FailTwice --
+// the predicate succeeds without having consumed anything. If e
+// succeeds, FailTwice discards that same choice point (it's done its
+// job either way) and fails using whatever choice point is next up
+// the stack, which is exactly what !e matching nothing is supposed to
+// do. OpCommit would be wrong here: it would jump forward and let
+// parsing continue as if the predicate matched.
+func (c *vmCompiler) compileNotNode(n *NotNode) {
+	span := n.Span()
+	choiceIP := c.emit(span, Instr{Op: OpChoice})
+	c.compile(n.Expr)
+	c.emit(span, Instr{Op: OpFailTwice})
+	c.program[choiceIP].Arg = len(c.program) - choiceIP
+}
+
+func (c *vmCompiler) compileLabeledNode(n *LabeledNode) {
+	span := n.Span()
+	choiceIP := c.emit(span, Instr{Op: OpChoice})
+	c.compile(n.Expr)
+	commitIP := c.emit(span, Instr{Op: OpCommit})
+	c.program[choiceIP].Arg = len(c.program) - choiceIP
+	c.emit(span, Instr{Op: OpThrow, Label: n.Label})
+	c.program[commitIP].Arg = len(c.program) - commitIP
+}
+
+func (c *vmCompiler) compileIdentifierNode(n *IdentifierNode) {
+	ip := c.emit(n.Span(), Instr{Op: OpCall, Rule: n.Value})
+	c.pendingCalls = append(c.pendingCalls, ip)
+}
+
+func (c *vmCompiler) compileLiteralNode(n *LiteralNode) {
+	for _, r := range n.Value {
+		c.emit(n.Span(), Instr{Op: OpChar, Char: r})
+	}
+}
+
+func (c *vmCompiler) compileRangeNode(n *RangeNode) {
+	lo := []rune(n.Left)[0]
+	hi := []rune(n.Right)[0]
+	c.emit(n.Span(), Instr{Op: OpRange, Lo: lo, Hi: hi})
+}
+
+// resolveCalls patches every OpCall whose target rule was compiled
+// with that rule's final entry point, converting the
+// symbolic Rule reference into a concrete jump target in Arg.
+func (c *vmCompiler) resolveCalls() {
+	for _, ip := range c.pendingCalls {
+		target, ok := c.entryPoints[c.program[ip].Rule]
+		if !ok {
+			continue
+		}
+		c.program[ip].Arg = target - ip
+	}
+}
+
+// GenVM compiles a grammar into a flat instruction stream for the
+// parsing machine, the bytecode counterpart to GenGo/GenRust's
+// generated source. The returned SourceMap lets a VM runtime report
+// failures against the original grammar positions instead of raw
+// instruction pointers.  The returned entry is the instruction pointer
+// the VM should start execution at: opt.EntryRule's entry point if
+// set, otherwise the first definition compiled.
+func GenVM(node Node, opt GenVMOptions) (program []Instr, entry int, sourceMap SourceMap, err error) {
+	c := newVMCompiler(opt)
+	c.compile(node)
+
+	if opt.EntryRule != "" {
+		ip, ok := c.entryPoints[opt.EntryRule]
+		if !ok {
+			return nil, 0, nil, fmt.Errorf("parsing: GenVM: unknown entry rule %q", opt.EntryRule)
+		}
+		entry = ip
+	}
+
+	return c.program, entry, c.sourceMap, nil
+}
@@ -0,0 +1,87 @@
+package parsing
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestCompileNotNodeEmitsFailTwice is the golden case the FailTwice
+// fix exists for: `!'x'` has to retire its own Choice frame without
+// backtracking into it when 'x' matches, which only OpFailTwice does.
+func TestCompileNotNodeEmitsFailTwice(t *testing.T) {
+	c := newVMCompiler(DefaultGenVMOptions())
+	c.compileNotNode(NewNotNode(lit("x"), Span{}))
+
+	want := []Instr{
+		{Op: OpChoice, Arg: 3},
+		{Op: OpChar, Char: 'x'},
+		{Op: OpFailTwice},
+	}
+	if !reflect.DeepEqual(c.program, want) {
+		t.Fatalf("compileNotNode program = %+v, want %+v", c.program, want)
+	}
+}
+
+// TestCompileAndNodeReusesNotNode checks that `&'x'` is built from the
+// same Choice/FailTwice shape as `!'x'` (nested twice, as `!!'x'`),
+// since compileAndNode is built on
+// top of compileNotNode and shouldn't have its own copy of the old
+// Commit+Fail bug to fix separately.
+func TestCompileAndNodeReusesNotNode(t *testing.T) {
+	c := newVMCompiler(DefaultGenVMOptions())
+	c.compileAndNode(NewAndNode(lit("x"), Span{}))
+
+	for _, instr := range c.program {
+		if instr.Op == OpCommit {
+			t.Errorf("compileAndNode program = %+v, still uses OpCommit instead of OpFailTwice", c.program)
+		}
+	}
+	if c.program[len(c.program)-1].Op != OpFailTwice {
+		t.Errorf("compileAndNode program = %+v, want it to end in OpFailTwice", c.program)
+	}
+}
+
+// TestGenVMEntryRuleResolvesToItsEntryPoint covers the EntryRule
+// option actually doing something: the returned entry should point at
+// B's instructions, not A's, even though A is declared first.
+func TestGenVMEntryRuleResolvesToItsEntryPoint(t *testing.T) {
+	g := grammar(rule("A", lit("a")), rule("B", lit("b")))
+
+	opt := DefaultGenVMOptions()
+	opt.EntryRule = "B"
+	program, entry, _, err := GenVM(g, opt)
+	if err != nil {
+		t.Fatalf("GenVM: %v", err)
+	}
+	if entry == 0 {
+		t.Fatalf("entry = %d, want B's entry point, not A's (0)", entry)
+	}
+	if program[entry].Op != OpSpanOpen {
+		t.Errorf("program[entry] = %+v, want the SpanOpen that starts every definition's body", program[entry])
+	}
+}
+
+func TestGenVMUnknownEntryRuleErrors(t *testing.T) {
+	g := grammar(rule("A", lit("a")))
+
+	opt := DefaultGenVMOptions()
+	opt.EntryRule = "Missing"
+	if _, _, _, err := GenVM(g, opt); err == nil {
+		t.Error("GenVM with an unknown EntryRule returned a nil error, want an error")
+	}
+}
+
+func TestCompileChoiceOfItemsBracketing(t *testing.T) {
+	c := newVMCompiler(DefaultGenVMOptions())
+	c.compileChoiceOfItems([]Node{lit("a"), lit("b")}, Span{})
+
+	want := []Instr{
+		{Op: OpChoice, Arg: 3},
+		{Op: OpChar, Char: 'a'},
+		{Op: OpCommit, Arg: 2},
+		{Op: OpChar, Char: 'b'},
+	}
+	if !reflect.DeepEqual(c.program, want) {
+		t.Fatalf("compileChoiceOfItems program = %+v, want %+v", c.program, want)
+	}
+}
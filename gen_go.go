@@ -9,11 +9,59 @@ type goCodeEmitter struct {
 	options     GenGoOptions
 	output      *strings.Builder
 	indentLevel int
+
+	// follow holds the FOLLOW sets computed from the grammar being
+	// emitted when options.Recovery is set; nil otherwise. It's
+	// populated once in visitGrammarNode and consulted by every
+	// labeled node beneath it.
+	follow FollowSet
+
+	// currentRule is the name of the DefinitionNode currently being
+	// visited, used to look up follow[currentRule] as the recovery
+	// synchronization set for any LabeledNode inside it.
+	currentRule string
+
+	// leftRecHeads maps every rule participating in left recursion
+	// to the name of the single rule in its cycle designated to run
+	// the seed-growing loop, when options.LeftRecursion is set.
+	// Populated once by GenGo before writeHeader runs, since the
+	// header needs to know whether any left-recursive rule exists at
+	// all.
+	leftRecHeads map[string]string
 }
 
 type GenGoOptions struct {
 	PackageName  string
 	StructSuffix string
+
+	// Memoize turns on packrat memoization for the generated
+	// parser, guaranteeing linear-time parsing at the cost of
+	// keeping a memo table around for the lifetime of a parse.
+	Memoize bool
+
+	// MemoizeRules, when non-empty, restricts memoization to the
+	// named rules instead of every rule in the grammar.  This is
+	// useful when only a handful of rules are responsible for the
+	// exponential blowup (e.g. the deeply left-factored ones) and
+	// the bookkeeping overhead isn't worth paying everywhere else.
+	MemoizeRules []string
+
+	// Recovery turns on error-recovery mode: a labeled failure no
+	// longer fails the whole parse. Instead the generated rule
+	// consumes input up to the FOLLOW set of the rule it happened
+	// in (computed by ComputeFirstFollow) and returns a
+	// parsing.NewValueError node so the surrounding grammar can keep
+	// going. There is currently no grammar syntax for annotating a
+	// rule-specific sync set by hand; every labeled node falls back
+	// to its enclosing rule's FOLLOW set.
+	Recovery bool
+
+	// LeftRecursion turns on Warth-style bounded seed-growing for
+	// rules that recurse into themselves (directly or through other
+	// rules) without consuming input first, which a plain recursive
+	// descent parser would otherwise loop on forever. See
+	// leftRecursiveGroups for how the rules participating are found.
+	LeftRecursion bool
 }
 
 func DefaultGenGoOptions() GenGoOptions {
@@ -24,21 +72,94 @@ func DefaultGenGoOptions() GenGoOptions {
 }
 
 func newGoCodeEmitter(opt GenGoOptions) *goCodeEmitter {
-	emitter := &goCodeEmitter{options: opt, output: &strings.Builder{}}
-	emitter.write(fmt.Sprintf(`package %s
+	return &goCodeEmitter{options: opt, output: &strings.Builder{}}
+}
+
+func (g *goCodeEmitter) writeHeader() {
+	opt := g.options
+	memoField := ""
+	memoInit := ""
+	if opt.Memoize {
+		memoField = "\n\tmemo map[parserMemoKey$StructSuffix]parserMemoEntry$StructSuffix"
+		memoInit = "\tp.memo = map[parserMemoKey$StructSuffix]parserMemoEntry$StructSuffix{}\n"
+	}
+
+	if len(g.leftRecHeads) > 0 {
+		memoField += "\n\tleftRecSeeds map[parserLeftRecKey$StructSuffix]*parserLeftRecFrame$StructSuffix"
+		memoInit += "\tp.leftRecSeeds = map[parserLeftRecKey$StructSuffix]*parserLeftRecFrame$StructSuffix{}\n"
+	}
+
+	imports := `	"github.com/clarete/langlang/go"`
+	if len(g.leftRecHeads) > 0 {
+		imports = "\t\"errors\"\n\n" + imports
+	}
+
+	memoTypes := ""
+	if opt.Memoize {
+		memoTypes = `
+// parserMemoKey identifies a packrat memo entry by the rule being
+// parsed and the cursor position the rule was entered at. Position
+// alone is enough: anything that happened before the rule was
+// entered, spacing included, is already folded into the cursor.
+type parserMemoKey$StructSuffix struct {
+	rule string
+	pos  parsing.Location
+}
+
+// parserMemoEntry caches everything needed to replay a previous
+// attempt at parsing a rule without re-running its body: the
+// resulting value and error, the cursor position to resume from, and
+// enough of a labeled throw to re-raise it faithfully on a hit.
+type parserMemoEntry$StructSuffix struct {
+	value     parsing.Value
+	err       error
+	end       parsing.Location
+	thrown    bool
+	label     string
+	labelSpan parsing.Span
+}
+`
+	}
+
+	if len(g.leftRecHeads) > 0 {
+		memoTypes += `
+// parserLeftRecKey identifies one in-progress bounded seed-growing
+// attempt by the name of the rule group's head and the cursor
+// position it started from: two different left-recursive groups can
+// both have a frame active at the same position (one nested inside
+// the other's body), so the head has to be part of the key alongside
+// pos.
+type parserLeftRecKey$StructSuffix struct {
+	head string
+	pos  parsing.Location
+}
+
+// parserLeftRecFrame tracks one in-progress bounded seed-growing
+// attempt: value/err hold the best match found so far, and end is the
+// cursor position it left off at, used to tell whether the next
+// attempt made any progress.
+type parserLeftRecFrame$StructSuffix struct {
+	value parsing.Value
+	err   error
+	end   parsing.Location
+}
+`
+	}
+
+	g.write(fmt.Sprintf(`package %s
 
 import (
-	"github.com/clarete/langlang/go"
+%s
 )
 
 type Parser$StructSuffix struct {
-	parsing.BaseParser
+	parsing.BaseParser%s
 }
-
+%s
 func NewParser$StructSuffix(input string) *Parser$StructSuffix {
 	p := &Parser$StructSuffix{}
 	p.SetInput([]rune(input))
-	return p
+%s	return p
 }
 
 func (p *Parser$StructSuffix) ParseAny() (parsing.Value, error) {
@@ -102,10 +223,14 @@ func (p *Parser$StructSuffix) ParseEOF() (parsing.Value, error) {
 		return parsing.NewValueSequence(items, parsing.NewSpan(start, p.Location())), nil
 	}(p))
 }
-`, opt.PackageName))
-	return emitter
+`, opt.PackageName, imports, memoField, memoTypes, memoInit))
 }
 
+// writeFooter is a no-op for the Go backend: every parser method is
+// already self-contained once visit has walked the grammar, so there
+// is nothing left to append after the last definition.
+func (g *goCodeEmitter) writeFooter() {}
+
 func (g *goCodeEmitter) visit(node Node) {
 	switch n := node.(type) {
 	case *GrammarNode:
@@ -142,12 +267,20 @@ func (g *goCodeEmitter) visit(node Node) {
 }
 
 func (g *goCodeEmitter) visitGrammarNode(n *GrammarNode) {
+	if g.options.Recovery {
+		_, follow, _ := ComputeFirstFollow(n)
+		g.follow = follow
+	}
+
 	for _, definition := range n.Items {
 		g.visit(definition)
 	}
 }
 
 func (g *goCodeEmitter) visitDefinitionNode(n *DefinitionNode) {
+	g.currentRule = n.Name
+	defer func() { g.currentRule = "" }()
+
 	g.writeIndent()
 	g.write("\nfunc (p *Parser$StructSuffix) Parse")
 	g.write(n.Name)
@@ -158,11 +291,171 @@ func (g *goCodeEmitter) visitDefinitionNode(n *DefinitionNode) {
 	fmt.Fprintf(g.output, `(parsing.TracerSpan{Name: "%s"})`, n.Name)
 	g.write("\n")
 	g.writei("defer p.PopTraceSpan()\n")
+
+	if head, ok := g.leftRecHeads[n.Name]; ok {
+		// Left-recursive rules get the bounded seed-growing wrapper
+		// instead of (rather than in addition to) packrat
+		// memoization: the grow loop already bounds how many times
+		// the body re-runs at a given position, so layering the
+		// generic memo frame on top would just be redundant
+		// bookkeeping.
+		g.writeLeftRecGuard(head)
+		if head == n.Name {
+			g.writeLeftRecGrow(n)
+		} else {
+			g.writei("return ")
+			g.visit(n.Expr)
+		}
+	} else if g.shouldMemoizeRule(n) {
+		g.writeMemoLookup(n)
+		g.writei("result, resultErr := func(p parsing.Parser) (parsing.Value, error) {\n")
+		g.indent()
+		g.writei("return ")
+		g.visit(n.Expr)
+		g.write("\n")
+		g.unindent()
+		g.writei("}(p)\n")
+		g.writeMemoStore()
+	} else {
+		g.writei("return ")
+		g.visit(n.Expr)
+	}
+
+	g.unindent()
+	g.write("\n}\n")
+}
+
+// writeLeftRecGuard emits the check every rule in a left-recursive
+// group makes before anything else: if this position already has an
+// active seed for the group's head, return it instead of recursing
+// into the body again. This is what bounds the recursion -- without
+// it, entering the cycle at the same position would recurse forever.
+// The key is (head, position) rather than position alone so that a
+// different left-recursive group with a frame active at the same
+// position (e.g. one nested inside this group's body) can't collide
+// with or delete this one's entry.
+func (g *goCodeEmitter) writeLeftRecGuard(head string) {
+	g.writei("leftRecPos := p.Location()\n")
+	g.writei(fmt.Sprintf("leftRecKey := parserLeftRecKey$StructSuffix{head: %q, pos: leftRecPos}\n", head))
+	g.writei("if frame, ok := p.leftRecSeeds[leftRecKey]; ok {\n")
+	g.indent()
+	g.writei("return frame.value, frame.err\n")
+	g.unindent()
+	g.writei("}\n")
+}
+
+// writeLeftRecGrow emits the seed-growing loop for a group's head
+// rule: parse the body with no seed yet (so every nested call to a
+// member of the cycle at leftRecPos hits writeLeftRecGuard and bails
+// out immediately with the current seed), and keep re-running it
+// from leftRecPos for as long as each attempt consumes more input
+// than the last. The loop always terminates because a rule's body
+// can't both make progress and stay the same length.
+//
+// grown tracks whether an attempt has ever succeeded yet: frame.end
+// starts out equal to leftRecPos, which is indistinguishable from "no
+// progress" if compared before the first attempt has even run. That
+// comparison would otherwise discard a genuine zero-width first match
+// (e.g. a nullable base case like `Expr <- Expr '+' Term / Term / ε`)
+// and fall through to the sentinel "made no progress" error instead
+// of the empty match it actually found. Requiring grown before the
+// position check means the first successful attempt is always stored
+// into frame, no matter how much input it consumed. If the very first
+// attempt fails outright, frame.err is overwritten with that attempt's
+// real error so callers see why the rule failed instead of the canned
+// sentinel.
+func (g *goCodeEmitter) writeLeftRecGrow(n *DefinitionNode) {
+	g.writei("frame := &parserLeftRecFrame$StructSuffix{err: errors.New(\"left-recursive rule made no progress\"), end: leftRecPos}\n")
+	g.writei("p.leftRecSeeds[leftRecKey] = frame\n")
+	g.writei("defer delete(p.leftRecSeeds, leftRecKey)\n")
+	g.writei("grown := false\n")
+	g.writei("for {\n")
+	g.indent()
+	g.writei("p.Seek(leftRecPos)\n")
+	g.writei("value, err := func(p parsing.Parser) (parsing.Value, error) {\n")
+	g.indent()
 	g.writei("return ")
 	g.visit(n.Expr)
+	g.write("\n")
+	g.unindent()
+	g.writei("}(p)\n")
+	g.writei("if err != nil {\n")
+	g.indent()
+	g.writei("if !grown {\n")
+	g.indent()
+	g.writei("frame.err = err\n")
+	g.unindent()
+	g.writei("}\n")
+	g.writei("break\n")
+	g.unindent()
+	g.writei("}\n")
+	g.writei("if grown && p.Location() == frame.end {\n")
+	g.indent()
+	g.writei("break\n")
+	g.unindent()
+	g.writei("}\n")
+	g.writei("grown = true\n")
+	g.writei("frame.value, frame.err, frame.end = value, err, p.Location()\n")
+	g.unindent()
+	g.writei("}\n")
+	g.writei("p.Seek(frame.end)\n")
+	g.writei("return frame.value, frame.err\n")
+}
+
+// shouldMemoizeRule reports whether the definition should be wrapped
+// in a packrat memo frame.  Syntactic rules (lexical atoms such as
+// literals and character classes) are cheap enough to re-run that
+// memoizing them only adds map-lookup overhead, so they're always
+// skipped regardless of MemoizeRules.
+func (g *goCodeEmitter) shouldMemoizeRule(n *DefinitionNode) bool {
+	if !g.options.Memoize || n.Expr.IsSyntactic() {
+		return false
+	}
+	if len(g.options.MemoizeRules) == 0 {
+		return true
+	}
+	for _, name := range g.options.MemoizeRules {
+		if name == n.Name {
+			return true
+		}
+	}
+	return false
+}
 
+// writeMemoLookup emits the memo key computation and the early
+// return taken on a cache hit.  On a hit, the cursor is restored to
+// wherever the cached attempt left it before the cached value, error
+// or replayed throw is returned.
+func (g *goCodeEmitter) writeMemoLookup(n *DefinitionNode) {
+	g.writei("memoStart := p.Location()\n")
+	g.writei(fmt.Sprintf("memoKey := parserMemoKey$StructSuffix{rule: %q, pos: memoStart}\n", n.Name))
+	g.writei("if entry, ok := p.memo[memoKey]; ok {\n")
+	g.indent()
+	g.writei("p.Seek(entry.end)\n")
+	g.writei("if entry.thrown {\n")
+	g.indent()
+	g.writei("return nil, p.Throw(entry.label, entry.labelSpan)\n")
 	g.unindent()
-	g.write("\n}\n")
+	g.writei("}\n")
+	g.writei("return entry.value, entry.err\n")
+	g.unindent()
+	g.writei("}\n")
+}
+
+// writeMemoStore emits the code that saves the freshly computed
+// result into the memo table, unpacking a labeled throw so it can be
+// replayed verbatim on a future hit, before returning the result.
+func (g *goCodeEmitter) writeMemoStore() {
+	g.writei("entry := parserMemoEntry$StructSuffix{value: result, err: resultErr, end: p.Location()}\n")
+	g.writei("if throwErr, ok := resultErr.(*parsing.ThrowError); ok {\n")
+	g.indent()
+	g.writei("entry.thrown = true\n")
+	g.writei("entry.label = throwErr.Label\n")
+	g.writei("entry.labelSpan = throwErr.Span\n")
+	g.unindent()
+	g.writei("}\n")
+	g.writei("p.memo[memoKey] = entry\n")
+	g.writei("return result, resultErr\n")
 }
 
 func (g *goCodeEmitter) visitSequenceNode(n *SequenceNode) {
@@ -320,12 +613,17 @@ func (g *goCodeEmitter) visitLabeledNode(n *LabeledNode) {
 	g.wirteExprFn(n.Expr)
 	g.write(",\n")
 
-	// if the expression failed, throw an error
+	// if the expression failed, throw an error (or, in recovery
+	// mode, sync past it and keep going instead of failing the rule)
 	g.writei("func(p parsing.Parser) (parsing.Value, error) {\n")
 	g.indent()
-	g.writei("return nil, p.Throw")
-	g.write(fmt.Sprintf(`("%s", parsing.NewSpan(start, p.Location()))`, n.Label))
-	g.write("\n")
+	if g.options.Recovery {
+		g.writeLabeledRecovery(n)
+	} else {
+		g.writei("return nil, p.Throw")
+		g.write(fmt.Sprintf(`("%s", parsing.NewSpan(start, p.Location()))`, n.Label))
+		g.write("\n")
+	}
 
 	g.unindent()
 	g.writei("},\n")
@@ -337,6 +635,38 @@ func (g *goCodeEmitter) visitLabeledNode(n *LabeledNode) {
 	g.writei("}(p)\n")
 }
 
+// writeLabeledRecovery emits the recovery-mode fallback for a labeled
+// node: sync the cursor past the failure using the enclosing rule's
+// FOLLOW set, then return a parsing.NewValueError node instead of an
+// error so the caller keeps parsing. The partial value is always nil
+// here: this recovery frame doesn't thread through whatever the
+// failed branch managed to match before giving up.
+func (g *goCodeEmitter) writeLabeledRecovery(n *LabeledNode) {
+	g.writei(fmt.Sprintf("p.(*Parser$StructSuffix).SyncTo(%s)\n", g.recoverySetLiteral()))
+	g.writei(fmt.Sprintf("return parsing.NewValueError(%q, parsing.NewSpan(start, p.Location()), nil), nil\n", n.Label))
+}
+
+// recoverySetLiteral renders the FOLLOW set of the rule currently
+// being visited as a Go []rune literal, falling back to nil (meaning
+// "sync to EOF") when nothing is known about what follows it.
+func (g *goCodeEmitter) recoverySetLiteral() string {
+	set := g.follow[g.currentRule]
+	if len(set) == 0 {
+		return "nil"
+	}
+
+	var b strings.Builder
+	b.WriteString("[]rune{")
+	for i, r := range set.sorted() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", r)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
 func (g *goCodeEmitter) visitIdentifierNode(n *IdentifierNode) {
 	s := "p.(*Parser$StructSuffix).Parse%s()"
 	if g.isAtRuleLevel() {
@@ -461,6 +791,20 @@ func (g *goCodeEmitter) String() string {
 
 func GenGo(node Node, opt GenGoOptions) (string, error) {
 	g := newGoCodeEmitter(opt)
+
+	// leftRecHeads has to be known before writeHeader runs: whether
+	// the generated parser needs the "errors" import and the left-
+	// recursion frame/key types at all depends on whether the
+	// grammar actually has a left-recursive rule, not just on
+	// whether opt.LeftRecursion was requested.
+	if opt.LeftRecursion {
+		if grammar, ok := node.(*GrammarNode); ok {
+			g.leftRecHeads = leftRecursiveGroups(grammar)
+		}
+	}
+
+	g.writeHeader()
 	g.visit(node)
+	g.writeFooter()
 	return g.String(), nil
 }
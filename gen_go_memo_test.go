@@ -0,0 +1,63 @@
+package parsing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenGoMemoizesNonSyntacticRules(t *testing.T) {
+	g := grammar(rule("S", NewSequenceNode([]Node{id("A")}, Span{})), rule("A", lit("a")))
+
+	opt := DefaultGenGoOptions()
+	opt.Memoize = true
+	out, err := GenGo(g, opt)
+	if err != nil {
+		t.Fatalf("GenGo: %v", err)
+	}
+
+	if !strings.Contains(out, "type parserMemoKey struct") {
+		t.Errorf("expected the memo key type to be emitted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "memoKey := parserMemoKey{rule: \"S\", pos: memoStart}") {
+		t.Errorf("expected ParseS to look up a memo entry keyed on (rule, pos), got:\n%s", out)
+	}
+	if strings.Contains(out, `rule: "A"`) {
+		t.Errorf("ParseA is a syntactic rule (a bare literal) and shouldn't be memoized, got:\n%s", out)
+	}
+}
+
+func TestGenGoMemoizeRulesRestrictsToNamedRules(t *testing.T) {
+	g := grammar(
+		rule("S", NewSequenceNode([]Node{id("A"), id("B")}, Span{})),
+		rule("A", NewSequenceNode([]Node{id("C"), lit("a")}, Span{})),
+		rule("B", NewSequenceNode([]Node{id("C"), lit("b")}, Span{})),
+		rule("C", lit("c")),
+	)
+
+	opt := DefaultGenGoOptions()
+	opt.Memoize = true
+	opt.MemoizeRules = []string{"A"}
+	out, err := GenGo(g, opt)
+	if err != nil {
+		t.Fatalf("GenGo: %v", err)
+	}
+
+	if !strings.Contains(out, `rule: "A"`) {
+		t.Errorf("expected A (named in MemoizeRules) to be memoized, got:\n%s", out)
+	}
+	if strings.Contains(out, `rule: "B"`) {
+		t.Errorf("expected B (not named in MemoizeRules) to be skipped, got:\n%s", out)
+	}
+}
+
+func TestGenGoNoMemoWithoutOption(t *testing.T) {
+	g := grammar(rule("S", NewSequenceNode([]Node{lit("a"), lit("a")}, Span{})))
+
+	out, err := GenGo(g, DefaultGenGoOptions())
+	if err != nil {
+		t.Fatalf("GenGo: %v", err)
+	}
+	if strings.Contains(out, "parserMemoKey") {
+		t.Errorf("expected no memo scaffolding without Memoize, got:\n%s", out)
+	}
+}
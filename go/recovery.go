@@ -0,0 +1,43 @@
+package parsing
+
+// SyncTo advances the parser's cursor past input runes until it finds
+// one that belongs to set, or runs out of input, whichever happens
+// first. Generated parsers built with GenGoOptions.Recovery call this
+// right after a labeled failure so parsing can resume at the next
+// position the surrounding grammar is actually prepared to see,
+// instead of failing the whole parse over one bad token.
+func (p *BaseParser) SyncTo(set []rune) {
+	if len(set) == 0 {
+		p.SyncToEOF()
+		return
+	}
+
+	target := make(map[rune]struct{}, len(set))
+	for _, r := range set {
+		target[r] = struct{}{}
+	}
+
+	for {
+		r, err := p.Peek()
+		if err != nil {
+			return
+		}
+		if _, ok := target[r]; ok {
+			return
+		}
+		if _, err := p.Any(); err != nil {
+			return
+		}
+	}
+}
+
+// SyncToEOF consumes every remaining rune. It's the fallback recovery
+// strategy used when a rule's FOLLOW set is empty, meaning nothing in
+// the grammar can tell us where it would be safe to resume.
+func (p *BaseParser) SyncToEOF() {
+	for {
+		if _, err := p.Any(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package parsing
+
+import "sort"
+
+// leftmostCalls returns, for each rule, the set of rules it can reach
+// while walking its leftmost spine -- the positions where it hasn't
+// consumed any input yet, which is exactly where left recursion
+// happens. Lex wrapping is transparent on purpose: "the first
+// non-lex step" of a rule is what actually determines whether a call
+// is left-recursive, a Lex node around it doesn't change that.
+//
+// This is a simpler approximation than the FIRST-set nullability
+// analysis in analysis.go: it only follows a SequenceNode's first
+// item, rather than walking past it when that item is nullable. Real
+// left-recursive grammars put the recursive call in that first slot,
+// so the approximation is enough to find the cycles GenGoOptions's
+// LeftRecursion mode needs to handle.
+func leftmostCalls(g *GrammarNode) map[string]map[string]bool {
+	calls := make(map[string]map[string]bool, len(g.Definitions))
+	for _, def := range g.Definitions {
+		set := map[string]bool{}
+		collectLeftmost(def.Expr, set)
+		calls[def.Name] = set
+	}
+	return calls
+}
+
+func collectLeftmost(node Node, acc map[string]bool) {
+	switch n := node.(type) {
+	case *IdentifierNode:
+		acc[n.Value] = true
+	case *SequenceNode:
+		if len(n.Items) > 0 {
+			collectLeftmost(n.Items[0], acc)
+		}
+	case *ChoiceNode:
+		for _, item := range n.Items {
+			collectLeftmost(item, acc)
+		}
+	case *OptionalNode:
+		collectLeftmost(n.Expr, acc)
+	case *ZeroOrMoreNode:
+		collectLeftmost(n.Expr, acc)
+	case *OneOrMoreNode:
+		collectLeftmost(n.Expr, acc)
+	case *AndNode:
+		collectLeftmost(n.Expr, acc)
+	case *NotNode:
+		collectLeftmost(n.Expr, acc)
+	case *LexNode:
+		collectLeftmost(n.Expr, acc)
+	case *LabeledNode:
+		collectLeftmost(n.Expr, acc)
+	}
+}
+
+// leftRecursiveGroups partitions the leftmost-call graph into its
+// strongly connected components (via tarjanSCCs) and assigns every
+// rule in a non-trivial component the name of its group's "head" --
+// the single rule, by the classic Warth bounded seed-growing
+// algorithm, responsible for driving the grow loop while every other
+// member just replays the current seed. A fully general
+// implementation picks the head dynamically off the call stack at
+// parse time; this picks it statically as whichever component member
+// is declared first in the grammar, which is simpler to generate code
+// for and correct for the common case of a cycle having one rule
+// that's clearly the "entry" (e.g. Expr in `Expr <- Expr '+' Term /
+// Term`).
+//
+// Components have to be found for the whole graph at once rather than
+// one simple cycle at a time: a rule can belong to the same mutually-
+// recursive cluster as another through more than one path (A calls B,
+// B calls both A and C, C calls B), and picking a cycle per-rule
+// independently can assign two members of that single cluster
+// different heads depending on which cycle happened to be found first.
+func leftRecursiveGroups(g *GrammarNode) map[string]string {
+	calls := leftmostCalls(g)
+	order := make(map[string]int, len(g.Definitions))
+	for i, def := range g.Definitions {
+		order[def.Name] = i
+	}
+
+	heads := map[string]string{}
+	for _, component := range tarjanSCCs(g, calls) {
+		if len(component) == 1 && !calls[component[0]][component[0]] {
+			continue // singleton with no self-loop: not left-recursive
+		}
+		head := component[0]
+		for _, name := range component[1:] {
+			if order[name] < order[head] {
+				head = name
+			}
+		}
+		for _, name := range component {
+			heads[name] = head
+		}
+	}
+	return heads
+}
+
+// tarjanSCCs partitions calls into its strongly connected components
+// using Tarjan's algorithm, visiting g.Definitions (and each node's
+// neighbors) in a fixed order so the same grammar always yields the
+// same components instead of depending on Go's randomized map
+// iteration order.
+func tarjanSCCs(g *GrammarNode, calls map[string]map[string]bool) [][]string {
+	index := 0
+	indices := map[string]int{}
+	lowlink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var strongconnect func(name string)
+	strongconnect = func(name string) {
+		indices[name] = index
+		lowlink[name] = index
+		index++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, next := range sortedKeys(calls[name]) {
+			if _, seen := indices[next]; !seen {
+				strongconnect(next)
+				if lowlink[next] < lowlink[name] {
+					lowlink[name] = lowlink[next]
+				}
+			} else if onStack[next] && indices[next] < lowlink[name] {
+				lowlink[name] = indices[next]
+			}
+		}
+
+		if lowlink[name] == indices[name] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == name {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, def := range g.Definitions {
+		if _, seen := indices[def.Name]; !seen {
+			strongconnect(def.Name)
+		}
+	}
+	return components
+}
+
+// sortedKeys returns the keys of set in ascending order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for name := range set {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
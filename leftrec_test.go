@@ -0,0 +1,86 @@
+package parsing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeftRecursiveGroupsDirect(t *testing.T) {
+	// Expr <- Expr '+' Term / Term
+	// Term <- 'x'
+	g := grammar(
+		rule("Expr", NewChoiceNode([]Node{
+			NewSequenceNode([]Node{id("Expr"), lit("+"), id("Term")}, Span{}),
+			id("Term"),
+		}, Span{})),
+		rule("Term", lit("x")),
+	)
+
+	heads := leftRecursiveGroups(g)
+	want := map[string]string{"Expr": "Expr"}
+	if !reflect.DeepEqual(heads, want) {
+		t.Errorf("leftRecursiveGroups = %v, want %v", heads, want)
+	}
+}
+
+func TestLeftRecursiveGroupsNoRecursion(t *testing.T) {
+	// S <- A 'x'
+	// A <- 'a'
+	g := grammar(
+		rule("S", NewSequenceNode([]Node{id("A"), lit("x")}, Span{})),
+		rule("A", lit("a")),
+	)
+
+	if heads := leftRecursiveGroups(g); len(heads) != 0 {
+		t.Errorf("leftRecursiveGroups = %v, want empty for a non-recursive grammar", heads)
+	}
+}
+
+// TestLeftRecursiveGroupsMutualCluster covers the bug the Tarjan
+// rewrite fixed: A and C only share a cycle through B (A -> B -> A is
+// one simple cycle, B -> C -> B is another), so a per-rule simple-
+// cycle search can assign A and C different heads depending on which
+// rule's DFS ran first. Since A, B and C form a single strongly
+// connected component, every member has to agree on one head.
+func TestLeftRecursiveGroupsMutualCluster(t *testing.T) {
+	// A <- B / 'a'
+	// B <- A / C / 'b'
+	// C <- B / 'c'
+	g := grammar(
+		rule("A", NewChoiceNode([]Node{id("B"), lit("a")}, Span{})),
+		rule("B", NewChoiceNode([]Node{id("A"), id("C"), lit("b")}, Span{})),
+		rule("C", NewChoiceNode([]Node{id("B"), lit("c")}, Span{})),
+	)
+
+	heads := leftRecursiveGroups(g)
+	if len(heads) != 3 {
+		t.Fatalf("leftRecursiveGroups = %v, want all three of A, B, C assigned a head", heads)
+	}
+	head := heads["A"]
+	for _, name := range []string{"A", "B", "C"} {
+		if heads[name] != head {
+			t.Errorf("heads[%s] = %q, want %q (every member of one SCC must share a head)", name, heads[name], head)
+		}
+	}
+	// The head is picked as whichever member is declared first in the
+	// grammar; A is declared first here.
+	if head != "A" {
+		t.Errorf("head = %q, want %q (first declared member of the component)", head, "A")
+	}
+}
+
+func TestTarjanSCCsDeterministic(t *testing.T) {
+	g := grammar(
+		rule("A", NewChoiceNode([]Node{id("B"), lit("a")}, Span{})),
+		rule("B", NewChoiceNode([]Node{id("A"), id("C"), lit("b")}, Span{})),
+		rule("C", NewChoiceNode([]Node{id("B"), lit("c")}, Span{})),
+	)
+	calls := leftmostCalls(g)
+
+	first := tarjanSCCs(g, calls)
+	for i := 0; i < 20; i++ {
+		if got := tarjanSCCs(g, calls); !reflect.DeepEqual(got, first) {
+			t.Fatalf("tarjanSCCs is nondeterministic: run 0 = %v, run %d = %v", first, i+1, got)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package parsing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// LoadOptions configures LoadGrammar.
+type LoadOptions struct {
+	// MaxOpenFiles bounds how many grammar files may be read and
+	// parsed concurrently. Defaults to runtime.GOMAXPROCS(0) when
+	// left at zero, mirroring the worker count go/build's loader
+	// uses for parsing a package's files.
+	MaxOpenFiles int
+}
+
+func DefaultLoadOptions() LoadOptions {
+	return LoadOptions{}
+}
+
+// fileResult carries the outcome of parsing a single grammar file
+// back from its worker goroutine to the collector in LoadGrammar.
+type fileResult struct {
+	path    string
+	grammar *GrammarNode
+	err     error
+}
+
+// LoadGrammar parses every file in paths concurrently, bounded to
+// opts.MaxOpenFiles files open at once, then resolves the ImportNodes
+// of paths[0] (the entry grammar) against the other parsed files and
+// merges everything into a single top-level GrammarNode. Imported
+// definitions that collide with a name already in the entry grammar
+// are renamed to the form "<importBaseName>_<name>" so both survive
+// in the merged DefsByName table; if even that renamed form collides
+// with an existing definition, a numeric suffix is appended until one
+// is free.
+//
+// paths[0] is treated as the entry point; every other path must be
+// reachable from one of its import statements or it is parsed for
+// nothing (LoadGrammar does not currently fan out across transitive
+// imports beyond the entry file's direct ones).
+func LoadGrammar(paths []string, opts LoadOptions) (*GrammarNode, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("parsing: LoadGrammar called with no paths")
+	}
+
+	maxOpen := opts.MaxOpenFiles
+	if maxOpen <= 0 {
+		maxOpen = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		sem     = make(chan struct{}, maxOpen)
+		results = make(chan fileResult, len(paths))
+		wg      sync.WaitGroup
+	)
+
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				results <- fileResult{path: path, err: err}
+				return
+			}
+
+			grammar, err := parseGrammarSource(path, src)
+			results <- fileResult{path: path, grammar: grammar, err: err}
+		}(path)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	parsed := make(map[string]*GrammarNode, len(paths))
+	var errs []error
+	for res := range results {
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		parsed[res.path] = res.grammar
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return mergeImports(paths[0], parsed)
+}
+
+// parseGrammarSource delegates to the bootstrap langlang parser to
+// turn a grammar file's contents into a GrammarNode, wrapping any
+// failure with the path it came from so concurrent parse errors
+// remain distinguishable once they're joined together.
+func parseGrammarSource(path string, src []byte) (*GrammarNode, error) {
+	node, err := NewLangLangParser(string(src)).ParseGrammar()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	grammar, ok := node.(*GrammarNode)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a grammar, got %T", path, node)
+	}
+	return grammar, nil
+}
+
+// mergeImports resolves entryPath's ImportNodes against the other
+// parsed files and folds the requested definitions into a copy of
+// the entry grammar.
+func mergeImports(entryPath string, parsed map[string]*GrammarNode) (*GrammarNode, error) {
+	entry, ok := parsed[entryPath]
+	if !ok {
+		return nil, fmt.Errorf("parsing: entry file %s was not parsed", entryPath)
+	}
+	if len(entry.Imports) == 0 {
+		return entry, nil
+	}
+
+	defsByName := make(map[string]*DefinitionNode, len(entry.DefsByName))
+	for name, def := range entry.DefsByName {
+		defsByName[name] = def
+	}
+	definitions := append([]*DefinitionNode{}, entry.Definitions...)
+
+	for _, imp := range entry.Imports {
+		depPath := filepath.Join(filepath.Dir(entryPath), imp.GetPath())
+		dep, ok := parsed[depPath]
+		if !ok {
+			return nil, fmt.Errorf("parsing: %s: unresolved import %q", entryPath, imp.GetPath())
+		}
+
+		names := imp.GetNames()
+		if len(names) == 0 {
+			names = make([]string, 0, len(dep.Definitions))
+			for _, def := range dep.Definitions {
+				names = append(names, def.Name)
+			}
+		}
+
+		rename := map[string]string{}
+		base := strings.TrimSuffix(filepath.Base(imp.GetPath()), filepath.Ext(imp.GetPath()))
+		for _, name := range names {
+			if _, collides := defsByName[name]; !collides {
+				continue
+			}
+			newName := fmt.Sprintf("%s_%s", base, name)
+			for n, suffix := newName, 2; ; n, suffix = fmt.Sprintf("%s_%d", newName, suffix), suffix+1 {
+				if _, taken := defsByName[n]; !taken {
+					newName = n
+					break
+				}
+			}
+			rename[name] = newName
+			defsByName[newName] = nil
+		}
+
+		for _, name := range names {
+			def, ok := dep.DefsByName[name]
+			if !ok {
+				return nil, fmt.Errorf("parsing: %s: import %q has no definition %q", entryPath, imp.GetPath(), name)
+			}
+
+			newName := name
+			if renamed, ok := rename[name]; ok {
+				newName = renamed
+			}
+
+			renamed := NewDefinitionNode(newName, renameIdentifiers(def.Expr, rename), def.Span())
+			defsByName[newName] = renamed
+			definitions = append(definitions, renamed)
+		}
+	}
+
+	return NewGrammarNode(entry.Imports, definitions, defsByName, entry.Span()), nil
+}
+
+// renameIdentifiers returns a copy of expr with every IdentifierNode
+// reference rewritten through rename, so a rule that got renamed to
+// avoid a collision keeps calling its sibling rules (which may have
+// been renamed too) correctly.  Nodes outside of rename pass through
+// unchanged; this only ever needs to rewrite the shallow set of nodes
+// that can contain an IdentifierNode.
+func renameIdentifiers(node Node, rename map[string]string) Node {
+	switch n := node.(type) {
+	case *IdentifierNode:
+		if newName, ok := rename[n.Value]; ok {
+			return NewIdentifierNode(newName, n.Span())
+		}
+		return n
+	case *SequenceNode:
+		return NewSequenceNode(renameItems(n.Items, rename), n.Span())
+	case *ChoiceNode:
+		return NewChoiceNode(renameItems(n.Items, rename), n.Span())
+	case *ClassNode:
+		return NewClassNode(renameItems(n.Items, rename), n.Span())
+	case *OneOrMoreNode:
+		return NewOneOrMoreNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *ZeroOrMoreNode:
+		return NewZeroOrMoreNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *OptionalNode:
+		return NewOptionalNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *AndNode:
+		return NewAndNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *NotNode:
+		return NewNotNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *LexNode:
+		return NewLexNode(renameIdentifiers(n.Expr, rename), n.Span())
+	case *LabeledNode:
+		return NewLabeledNode(n.Label, renameIdentifiers(n.Expr, rename), n.Span())
+	default:
+		return node
+	}
+}
+
+func renameItems(items []Node, rename map[string]string) []Node {
+	out := make([]Node, len(items))
+	for i, item := range items {
+		out[i] = renameIdentifiers(item, rename)
+	}
+	return out
+}
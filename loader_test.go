@@ -0,0 +1,95 @@
+package parsing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func defNames(defs map[string]*DefinitionNode) []string {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	return names
+}
+
+func writeGrammarFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("os.WriteFile(%s): %v", path, err)
+	}
+	return path
+}
+
+func TestLoadGrammarMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadGrammar([]string{filepath.Join(dir, "missing.peg")}, DefaultLoadOptions()); err == nil {
+		t.Error("LoadGrammar on a missing file returned a nil error, want an error")
+	}
+}
+
+func TestLoadGrammarMergesImportWithoutCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeGrammarFile(t, dir, "dep.peg", "B <- 'y'\n")
+	entry := writeGrammarFile(t, dir, "main.peg", "import B from \"dep.peg\"\n\nA <- 'x'\n")
+
+	g, err := LoadGrammar([]string{entry, filepath.Join(dir, "dep.peg")}, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadGrammar: %v", err)
+	}
+	for _, name := range []string{"A", "B"} {
+		if _, ok := g.DefsByName[name]; !ok {
+			t.Errorf("DefsByName = %v, want a definition named %q", defNames(g.DefsByName), name)
+		}
+	}
+}
+
+func TestLoadGrammarRenamesOnCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeGrammarFile(t, dir, "dep.peg", "B <- 'y'\n")
+	entry := writeGrammarFile(t, dir, "main.peg", "import B from \"dep.peg\"\n\nB <- 'x'\n")
+
+	g, err := LoadGrammar([]string{entry, filepath.Join(dir, "dep.peg")}, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadGrammar: %v", err)
+	}
+
+	entryB, ok := g.DefsByName["B"]
+	if !ok {
+		t.Fatal("DefsByName has no entry named B, want the entry grammar's own B to survive under its original name")
+	}
+	if lit, ok := entryB.Expr.(*LiteralNode); !ok || lit.Value != "x" {
+		t.Errorf("DefsByName[B].Expr = %#v, want the entry grammar's own 'x' literal, not the imported one", entryB.Expr)
+	}
+
+	renamed, ok := g.DefsByName["dep_B"]
+	if !ok {
+		t.Fatalf("DefsByName = %v, want the imported B renamed to dep_B", defNames(g.DefsByName))
+	}
+	if lit, ok := renamed.Expr.(*LiteralNode); !ok || lit.Value != "y" {
+		t.Errorf("DefsByName[dep_B].Expr = %#v, want the imported 'y' literal", renamed.Expr)
+	}
+}
+
+func TestLoadGrammarUniquifiesRenamedCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeGrammarFile(t, dir, "dep.peg", "B <- 'y'\n")
+	entry := writeGrammarFile(t, dir, "main.peg",
+		"import B from \"dep.peg\"\n\nB <- 'x'\ndep_B <- 'z'\n")
+
+	g, err := LoadGrammar([]string{entry, filepath.Join(dir, "dep.peg")}, DefaultLoadOptions())
+	if err != nil {
+		t.Fatalf("LoadGrammar: %v", err)
+	}
+
+	if _, ok := g.DefsByName["dep_B_2"]; !ok {
+		t.Fatalf("DefsByName = %v, want the doubly-colliding import renamed to dep_B_2", defNames(g.DefsByName))
+	}
+	if own, ok := g.DefsByName["dep_B"]; !ok {
+		t.Fatal("DefsByName has no entry named dep_B, want the entry grammar's own dep_B rule to survive untouched")
+	} else if lit, ok := own.Expr.(*LiteralNode); !ok || lit.Value != "z" {
+		t.Errorf("DefsByName[dep_B].Expr = %#v, want the entry grammar's own 'z' literal", own.Expr)
+	}
+}
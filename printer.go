@@ -0,0 +1,116 @@
+package parsing
+
+import "fmt"
+
+// Precedence levels used by Printer to decide when a child
+// expression needs parentheses to parse back the way it printed.
+// Lower binds looser; a child is wrapped whenever its own precedence
+// is lower than what its parent requires.
+const (
+	precChoice = iota + 1
+	precSequence
+	precPrefix
+	precSuffix
+	precPrimary
+)
+
+// Printer renders an AstNode back into canonical grammar source,
+// the same role printer.go plays for cmd/compile/internal/syntax: a
+// single, precedence-aware renderer that every node's ad-hoc Text()
+// method used to reimplement (and disagree about) on its own. It
+// correctly parenthesizes ChoiceNode/SequenceNode nesting, wraps
+// LabeledNode around whatever actually needs it, and only emits
+// LexNode's "#(...)" grouping form when the inner expression would
+// otherwise print ambiguously.
+type Printer struct{}
+
+// NewPrinter returns a Printer.
+func NewPrinter() *Printer { return &Printer{} }
+
+// Print renders node as canonical grammar text.
+func (p *Printer) Print(node AstNode) string {
+	return p.print(node, precChoice)
+}
+
+func (p *Printer) print(node AstNode, minPrec int) string {
+	text, prec := p.render(node)
+	if prec < minPrec {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (p *Printer) render(node AstNode) (string, int) {
+	switch n := node.(type) {
+	case *AnyNode:
+		return ".", precPrimary
+	case *LiteralNode:
+		return fmt.Sprintf("'%s'", n.Value), precPrimary
+	case *IdentifierNode:
+		return n.Value, precPrimary
+	case *RangeNode:
+		return fmt.Sprintf("%s-%s", n.Left, n.Right), precPrimary
+	case *ClassNode:
+		return fmt.Sprintf("[%s]", p.joinItems(n.Items, "", precChoice)), precPrimary
+	case *LexNode:
+		return p.renderLex(n), precPrimary
+	case *OptionalNode:
+		return p.print(n.Expr, precPrimary) + "?", precSuffix
+	case *ZeroOrMoreNode:
+		return p.print(n.Expr, precPrimary) + "*", precSuffix
+	case *OneOrMoreNode:
+		return p.print(n.Expr, precPrimary) + "+", precSuffix
+	case *AndNode:
+		return "&" + p.print(n.Expr, precSuffix), precPrefix
+	case *NotNode:
+		return "!" + p.print(n.Expr, precSuffix), precPrefix
+	case *LabeledNode:
+		return p.print(n.Expr, precSuffix) + "^" + n.Label, precSuffix
+	case *SequenceNode:
+		return p.joinItems(n.Items, " ", precPrefix), precSequence
+	case *ChoiceNode:
+		return p.joinItems(n.Items, " / ", precSequence), precChoice
+	case *DefinitionNode:
+		return fmt.Sprintf("%s <- %s", n.Name, p.print(n.Expr, precChoice)), precChoice
+	case *ImportNode:
+		return fmt.Sprintf("import %s from %q", joinNames(n.GetNames()), n.GetPath()), precChoice
+	case *GrammarNode:
+		return p.joinItems(n.GetItems(), "\n", precChoice), precChoice
+	default:
+		return node.Text(), precPrimary
+	}
+}
+
+// renderLex re-emits a LexNode's "#" marker, wrapping its inner
+// expression in parentheses whenever it is not a primary expression
+// (a sequence, choice, prefix or suffix form): "#x" and "#x y" parse
+// to different things, so "#x" stays bare while a sequence becomes
+// "#(x y)".
+func (p *Printer) renderLex(n *LexNode) string {
+	if _, ok := n.Expr.(*SequenceNode); ok {
+		return fmt.Sprintf("#(%s)", p.print(n.Expr, precChoice))
+	}
+	return "#" + p.print(n.Expr, precPrimary)
+}
+
+func (p *Printer) joinItems(items []AstNode, sep string, minPrec int) string {
+	out := ""
+	for i, item := range items {
+		if i > 0 {
+			out += sep
+		}
+		out += p.print(item, minPrec)
+	}
+	return out
+}
+
+func joinNames(names []string) string {
+	out := ""
+	for i, name := range names {
+		if i > 0 {
+			out += ", "
+		}
+		out += name
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package parsing
+
+import "testing"
+
+func TestPrinterRoundTripsSimpleRule(t *testing.T) {
+	n := rule("S", NewSequenceNode([]Node{id("A"), lit("b")}, Span{}))
+
+	got := NewPrinter().Print(n)
+	want := "S <- A 'b'"
+	if got != want {
+		t.Errorf("Print() = %q, want %q", got, want)
+	}
+}
+
+// TestPrinterParenthesizesChoiceInsideSequence covers the reason
+// Printer exists over each node's own Text(): a choice nested in a
+// sequence has to be parenthesized or it reparses as a looser-binding
+// top-level choice instead of one alternative among the sequence's
+// items.
+func TestPrinterParenthesizesChoiceInsideSequence(t *testing.T) {
+	choice := NewChoiceNode([]Node{lit("a"), lit("b")}, Span{})
+	n := NewSequenceNode([]Node{choice, lit("c")}, Span{})
+
+	got := NewPrinter().Print(n)
+	want := "('a' / 'b') 'c'"
+	if got != want {
+		t.Errorf("Print() = %q, want %q", got, want)
+	}
+}
+
+// TestPrinterLexOnlyGroupsSequences checks renderLex's narrower
+// grouping rule: "#x" needs no parentheses, but "#(x y)" does, since
+// "#x y" would parse as the lex marker applying to just x.
+func TestPrinterLexOnlyGroupsSequences(t *testing.T) {
+	single := NewLexNode(lit("a"), Span{})
+	if got, want := NewPrinter().Print(single), "#'a'"; got != want {
+		t.Errorf("Print(single) = %q, want %q", got, want)
+	}
+
+	seq := NewLexNode(NewSequenceNode([]Node{lit("a"), lit("b")}, Span{}), Span{})
+	if got, want := NewPrinter().Print(seq), "#('a' 'b')"; got != want {
+		t.Errorf("Print(seq) = %q, want %q", got, want)
+	}
+}